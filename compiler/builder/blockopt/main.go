@@ -0,0 +1,390 @@
+// Package blockopt cleans up the bloated CFGs that fall out of
+// generateConditional and generateWhileStatement always allocating
+// fresh then/else/end blocks, even when some end up unreachable or
+// contain nothing but an unconditional branch.
+//
+// Run iterates three cleanups to a fixed point:
+//
+//   - unreachable-block sweep: mark-and-sweep from the entry block,
+//     dropping anything not reached and any phi incomings it contributed.
+//   - jump threading: a block whose only instruction is an unconditional
+//     branch is spliced out, redirecting its predecessors straight to
+//     its target.
+//   - block merging: a block with exactly one predecessor, reached by
+//     that predecessor's unconditional branch, is folded into it.
+package blockopt
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/value"
+)
+
+// Run cleans up fn's CFG in place.
+func Run(fn *ir.Func) {
+	for {
+		changed := false
+		if removeUnreachable(fn) {
+			changed = true
+		}
+		if threadJumps(fn) {
+			changed = true
+		}
+		if mergeBlocks(fn) {
+			changed = true
+		}
+		if !changed {
+			return
+		}
+	}
+}
+
+// --- CFG helpers -----------------------------------------------------
+
+func successors(b *ir.Block) []*ir.Block {
+	switch term := b.Term.(type) {
+	case *ir.TermBr:
+		return []*ir.Block{term.Target}
+	case *ir.TermCondBr:
+		return []*ir.Block{term.TargetTrue, term.TargetFalse}
+	default:
+		return nil
+	}
+}
+
+func buildPreds(blocks []*ir.Block) map[*ir.Block][]*ir.Block {
+	preds := make(map[*ir.Block][]*ir.Block, len(blocks))
+	for _, b := range blocks {
+		for _, s := range successors(b) {
+			preds[s] = append(preds[s], b)
+		}
+	}
+	return preds
+}
+
+func removeBlock(blocks []*ir.Block, b *ir.Block) []*ir.Block {
+	out := blocks[:0]
+	for _, x := range blocks {
+		if x != b {
+			out = append(out, x)
+		}
+	}
+	return out
+}
+
+// --- Unreachable-block sweep -------------------------------------------
+
+func removeUnreachable(fn *ir.Func) bool {
+	if len(fn.Blocks) == 0 {
+		return false
+	}
+
+	reachable := make(map[*ir.Block]bool, len(fn.Blocks))
+	var visit func(b *ir.Block)
+	visit = func(b *ir.Block) {
+		if reachable[b] {
+			return
+		}
+		reachable[b] = true
+		for _, s := range successors(b) {
+			visit(s)
+		}
+	}
+	visit(fn.Blocks[0])
+
+	if len(reachable) == len(fn.Blocks) {
+		return false
+	}
+
+	kept := fn.Blocks[:0]
+	for _, b := range fn.Blocks {
+		if reachable[b] {
+			kept = append(kept, b)
+		}
+	}
+
+	for _, b := range kept {
+		dropPhiIncomings(b, func(pred *ir.Block) bool { return !reachable[pred] })
+	}
+
+	fn.Blocks = kept
+	return true
+}
+
+func dropPhiIncomings(b *ir.Block, drop func(pred *ir.Block) bool) {
+	for _, inst := range b.Insts {
+		phi, ok := inst.(*ir.InstPhi)
+		if !ok {
+			continue
+		}
+		kept := phi.Incs[:0]
+		for _, inc := range phi.Incs {
+			if !drop(inc.Pred) {
+				kept = append(kept, inc)
+			}
+		}
+		phi.Incs = kept
+	}
+}
+
+// --- Jump threading ------------------------------------------------------
+
+// threadJumps splices out blocks whose only content is an unconditional
+// branch, redirecting their predecessors straight to the target. The
+// entry block is never spliced out, since callers branch into it
+// implicitly by calling the function.
+func threadJumps(fn *ir.Func) bool {
+	if len(fn.Blocks) == 0 {
+		return false
+	}
+
+	changed := false
+	for {
+		preds := buildPreds(fn.Blocks)
+
+		var empty, target *ir.Block
+		for _, b := range fn.Blocks[1:] {
+			br, ok := b.Term.(*ir.TermBr)
+			if !ok || len(b.Insts) != 0 || br.Target == b {
+				continue
+			}
+			if wouldDuplicatePred(preds, b, br.Target) {
+				// Splicing b out would give target two incoming edges
+				// from the same predecessor (e.g. two empty relay
+				// blocks sharing a predecessor that both branch to
+				// target), which its phis can't represent. Leave b in
+				// place rather than produce invalid IR.
+				continue
+			}
+			empty, target = b, br.Target
+			break
+		}
+
+		if empty == nil {
+			return changed
+		}
+
+		for _, p := range preds[empty] {
+			redirectTerm(p.Term, empty, target)
+		}
+		retargetPhis(target, empty, preds[empty])
+
+		fn.Blocks = removeBlock(fn.Blocks, empty)
+		changed = true
+	}
+}
+
+// wouldDuplicatePred reports whether splicing out empty in favor of
+// target would leave target with two incoming edges from the same
+// predecessor block. A phi takes exactly one value per predecessor
+// block, so that can't be represented and must be avoided rather than
+// silently producing a phi with a repeated predecessor.
+func wouldDuplicatePred(preds map[*ir.Block][]*ir.Block, empty, target *ir.Block) bool {
+	seen := make(map[*ir.Block]bool, len(preds[target]))
+	for _, p := range preds[target] {
+		if p != empty {
+			seen[p] = true
+		}
+	}
+	for _, p := range preds[empty] {
+		if seen[p] {
+			return true
+		}
+		seen[p] = true
+	}
+	return false
+}
+
+func redirectTerm(term ir.Terminator, from, to *ir.Block) {
+	switch t := term.(type) {
+	case *ir.TermBr:
+		if t.Target == from {
+			t.Target = to
+		}
+	case *ir.TermCondBr:
+		if t.TargetTrue == from {
+			t.TargetTrue = to
+		}
+		if t.TargetFalse == from {
+			t.TargetFalse = to
+		}
+	}
+}
+
+// retargetPhis replaces a phi incoming from a spliced-out block with one
+// incoming per block that used to branch into it, carrying the same
+// value (the spliced block had no instructions of its own).
+func retargetPhis(target, from *ir.Block, newPreds []*ir.Block) {
+	for _, inst := range target.Insts {
+		phi, ok := inst.(*ir.InstPhi)
+		if !ok {
+			continue
+		}
+
+		var val value.Value
+		kept := phi.Incs[:0]
+		for _, inc := range phi.Incs {
+			if inc.Pred == from {
+				val = inc.X
+				continue
+			}
+			kept = append(kept, inc)
+		}
+		phi.Incs = kept
+
+		for _, p := range newPreds {
+			phi.Incs = append(phi.Incs, ir.NewIncoming(val, p))
+		}
+	}
+}
+
+// --- Block merging -------------------------------------------------------
+
+// mergeBlocks folds a block into its unique predecessor when that
+// predecessor's terminator is an unconditional branch to it.
+func mergeBlocks(fn *ir.Func) bool {
+	changed := false
+	for {
+		preds := buildPreds(fn.Blocks)
+
+		var p, c *ir.Block
+		for _, b := range fn.Blocks {
+			ps := preds[b]
+			if len(ps) != 1 || ps[0] == b {
+				continue
+			}
+			br, ok := ps[0].Term.(*ir.TermBr)
+			if !ok || br.Target != b {
+				continue
+			}
+			p, c = ps[0], b
+			break
+		}
+
+		if c == nil {
+			return changed
+		}
+
+		resolvePhis(fn, c)
+		p.Insts = append(p.Insts, c.Insts...)
+		p.Term = c.Term
+		retargetPhiPreds(fn, c, p)
+
+		fn.Blocks = removeBlock(fn.Blocks, c)
+		changed = true
+	}
+}
+
+// resolvePhis replaces every phi in c with its single incoming value
+// (c has exactly one predecessor by the time mergeBlocks calls this)
+// and drops the now-dead phi.
+func resolvePhis(fn *ir.Func, c *ir.Block) {
+	kept := c.Insts[:0]
+	for _, inst := range c.Insts {
+		phi, ok := inst.(*ir.InstPhi)
+		if !ok {
+			kept = append(kept, inst)
+			continue
+		}
+		if len(phi.Incs) > 0 {
+			replaceUses(fn, phi, phi.Incs[0].X)
+		}
+	}
+	c.Insts = kept
+}
+
+func retargetPhiPreds(fn *ir.Func, from, to *ir.Block) {
+	for _, b := range fn.Blocks {
+		for _, inst := range b.Insts {
+			phi, ok := inst.(*ir.InstPhi)
+			if !ok {
+				continue
+			}
+			for _, inc := range phi.Incs {
+				if inc.Pred == from {
+					inc.Pred = to
+				}
+			}
+		}
+	}
+}
+
+// --- Operand rewriting ---------------------------------------------------
+
+func replaceUses(fn *ir.Func, old, new value.Value) {
+	for _, b := range fn.Blocks {
+		for _, inst := range b.Insts {
+			for _, ptr := range operandPtrs(inst) {
+				if *ptr == old {
+					*ptr = new
+				}
+			}
+		}
+		for _, ptr := range termOperandPtrs(b.Term) {
+			if *ptr == old {
+				*ptr = new
+			}
+		}
+	}
+}
+
+// operandPtrs returns a pointer to every value operand of inst that can
+// reference another instruction's result, deliberately excluding the
+// sanctioned pointer position of loads and stores (InstLoad.Src and
+// InstStore.Dst). Every instruction kind the builder can emit belongs in
+// this single exhaustive walk, so replaceUses can't silently miss an
+// operand slot when a new instruction kind is added.
+func operandPtrs(inst ir.Instruction) []*value.Value {
+	switch in := inst.(type) {
+	case *ir.InstStore:
+		return []*value.Value{&in.Src}
+	case *ir.InstCall:
+		ptrs := make([]*value.Value, len(in.Args))
+		for i := range in.Args {
+			ptrs[i] = &in.Args[i]
+		}
+		return ptrs
+	case *ir.InstAdd:
+		return []*value.Value{&in.X, &in.Y}
+	case *ir.InstSub:
+		return []*value.Value{&in.X, &in.Y}
+	case *ir.InstMul:
+		return []*value.Value{&in.X, &in.Y}
+	case *ir.InstSDiv:
+		return []*value.Value{&in.X, &in.Y}
+	case *ir.InstSRem:
+		return []*value.Value{&in.X, &in.Y}
+	case *ir.InstICmp:
+		return []*value.Value{&in.X, &in.Y}
+	case *ir.InstZExt:
+		return []*value.Value{&in.From}
+	case *ir.InstGetElementPtr:
+		ptrs := make([]*value.Value, 0, 1+len(in.Indices))
+		ptrs = append(ptrs, &in.Src)
+		for i := range in.Indices {
+			ptrs = append(ptrs, &in.Indices[i])
+		}
+		return ptrs
+	case *ir.InstPhi:
+		ptrs := make([]*value.Value, len(in.Incs))
+		for i, inc := range in.Incs {
+			ptrs[i] = &inc.X
+		}
+		return ptrs
+	default:
+		return nil
+	}
+}
+
+func termOperandPtrs(term ir.Terminator) []*value.Value {
+	switch t := term.(type) {
+	case *ir.TermRet:
+		if t.X == nil {
+			return nil
+		}
+		return []*value.Value{&t.X}
+	case *ir.TermCondBr:
+		return []*value.Value{&t.Cond}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,134 @@
+package blockopt
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+)
+
+// TestRun_RemovesUnreachableBlock covers the unreachable-block sweep: a
+// block with no path from the entry block is dropped entirely.
+func TestRun_RemovesUnreachableBlock(t *testing.T) {
+	module := ir.NewModule()
+	fn := module.NewFunc("f", types.I32)
+	entry := fn.NewBlock("")
+	used := fn.NewBlock("")
+	dead := fn.NewBlock("")
+
+	entry.NewBr(used)
+	used.NewRet(constant.NewInt(types.I32, 1))
+	dead.NewRet(constant.NewInt(types.I32, 2))
+
+	Run(fn)
+
+	got := fn.LLString()
+	if !strings.Contains(got, "ret i32 1") {
+		t.Fatalf("expected the reachable return to survive, got:\n%s", got)
+	}
+	if strings.Contains(got, "ret i32 2") {
+		t.Fatalf("expected the unreachable block's return to be swept, got:\n%s", got)
+	}
+}
+
+// TestRun_ThreadsEmptyRelayBlocks covers jump threading: a block whose
+// only content is an unconditional branch is spliced out and its
+// predecessors are redirected straight to its target. relayT and relayF
+// here share a single predecessor (entry), so threading both away would
+// give final two incoming edges from entry, which its phi can't
+// represent (a phi takes exactly one value per predecessor block); only
+// one of them can be threaded, and the result must stay valid IR.
+func TestRun_ThreadsEmptyRelayBlocks(t *testing.T) {
+	module := ir.NewModule()
+	fn := module.NewFunc("f", types.I32, ir.NewParam("cond", types.I1))
+	cond := fn.Params[0]
+
+	entry := fn.NewBlock("")
+	relayT := fn.NewBlock("")
+	relayF := fn.NewBlock("")
+	final := fn.NewBlock("")
+
+	entry.NewCondBr(cond, relayT, relayF)
+	relayT.NewBr(final)
+	relayF.NewBr(final)
+	phi := final.NewPhi(
+		ir.NewIncoming(constant.NewInt(types.I32, 1), relayT),
+		ir.NewIncoming(constant.NewInt(types.I32, 2), relayF),
+	)
+	final.NewRet(phi)
+
+	Run(fn)
+
+	assertNoDuplicatePredPhis(t, fn)
+	assertNoDegenerateCondBr(t, fn)
+
+	if len(fn.Blocks) != 3 {
+		t.Fatalf("expected exactly one of the two conflicting relay blocks to be threaded away, got %d blocks:\n%s", len(fn.Blocks), fn.LLString())
+	}
+}
+
+// assertNoDuplicatePredPhis fails the test if any phi in fn has two
+// incomings from the same predecessor block, which LLVM's phi can't
+// represent (exactly one value per predecessor block).
+func assertNoDuplicatePredPhis(t *testing.T, fn *ir.Func) {
+	t.Helper()
+	for _, b := range fn.Blocks {
+		for _, inst := range b.Insts {
+			phi, ok := inst.(*ir.InstPhi)
+			if !ok {
+				continue
+			}
+			seen := make(map[*ir.Block]bool, len(phi.Incs))
+			for _, inc := range phi.Incs {
+				if seen[inc.Pred] {
+					t.Fatalf("phi in %s has two incomings from the same predecessor:\n%s", b.Ident(), fn.LLString())
+				}
+				seen[inc.Pred] = true
+			}
+		}
+	}
+}
+
+// assertNoDegenerateCondBr fails the test if any CondBr branches to the
+// same target on both arms, which instcombine would fold to an
+// unconditional branch and which jump threading must not produce as a
+// side effect of redirecting two arms into the same relay target.
+func assertNoDegenerateCondBr(t *testing.T, fn *ir.Func) {
+	t.Helper()
+	for _, b := range fn.Blocks {
+		if term, ok := b.Term.(*ir.TermCondBr); ok && term.TargetTrue == term.TargetFalse {
+			t.Fatalf("%s has a condbr with both arms targeting the same block:\n%s", b.Ident(), fn.LLString())
+		}
+	}
+}
+
+// TestRun_MergesSinglePredecessorBlock covers block merging: a block
+// with exactly one predecessor, reached by that predecessor's
+// unconditional branch, is folded directly into it.
+func TestRun_MergesSinglePredecessorBlock(t *testing.T) {
+	module := ir.NewModule()
+	fn := module.NewFunc("f", types.I32, ir.NewParam("n", types.I32))
+	n := fn.Params[0]
+
+	entry := fn.NewBlock("")
+	cont := fn.NewBlock("")
+
+	entry.NewBr(cont)
+	sum := cont.NewAdd(n, constant.NewInt(types.I32, 1))
+	cont.NewRet(sum)
+
+	Run(fn)
+
+	if len(fn.Blocks) != 1 {
+		t.Fatalf("expected cont to be merged into entry, got %d blocks:\n%s", len(fn.Blocks), fn.LLString())
+	}
+	if _, ok := fn.Blocks[0].Term.(*ir.TermRet); !ok {
+		t.Fatalf("expected the merged block to end in cont's ret, got:\n%s", fn.LLString())
+	}
+	got := fn.LLString()
+	if !strings.Contains(got, "add i32") || !strings.Contains(got, "ret i32") {
+		t.Fatalf("expected the merged block to retain cont's add and ret, got:\n%s", got)
+	}
+}
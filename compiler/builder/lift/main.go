@@ -0,0 +1,461 @@
+// Package lift implements mem2reg: promoting stack allocations that are
+// only ever loaded and stored (never address-taken) to pure SSA values,
+// inserting phi nodes where control flow merges.
+//
+// The algorithm follows Cytron et al.: compute the dominator tree with
+// the iterative Cooper/Harvey/Kennedy method (blocks here are typically
+// small, so the simpler fixed-point algorithm beats Lengauer-Tarjan in
+// practice), derive dominance frontiers, place phis at the iterated
+// dominance frontier of each liftable alloca's store set, then rename
+// loads/stores via a stack-per-alloca walk of the dominator tree.
+package lift
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// Run promotes every liftable alloca in fn to SSA form in place.
+func Run(fn *ir.Func) {
+	if len(fn.Blocks) == 0 {
+		return
+	}
+
+	allocas := liftableAllocas(fn)
+	if len(allocas) == 0 {
+		return
+	}
+
+	entry := fn.Blocks[0]
+	preds := buildPreds(fn.Blocks)
+	idom := computeDominators(fn.Blocks, preds)
+	children := dominatorChildren(idom)
+	frontier := computeDominanceFrontier(fn.Blocks, idom, preds)
+
+	infos := make([]*allocaInfo, 0, len(allocas))
+	for _, a := range allocas {
+		infos = append(infos, &allocaInfo{
+			alloca: a,
+			defs:   defBlocks(fn, a),
+			phis:   make(map[*ir.Block]*ir.InstPhi),
+		})
+	}
+
+	for _, info := range infos {
+		insertPhis(info, frontier, preds)
+	}
+
+	renameAndRemove(fn, entry, children, infos)
+}
+
+type allocaInfo struct {
+	alloca *ir.InstAlloca
+	defs   map[*ir.Block]bool
+	phis   map[*ir.Block]*ir.InstPhi
+}
+
+// --- CFG helpers -----------------------------------------------------
+
+func successors(b *ir.Block) []*ir.Block {
+	switch term := b.Term.(type) {
+	case *ir.TermBr:
+		return []*ir.Block{term.Target}
+	case *ir.TermCondBr:
+		return []*ir.Block{term.TargetTrue, term.TargetFalse}
+	default:
+		return nil
+	}
+}
+
+func buildPreds(blocks []*ir.Block) map[*ir.Block][]*ir.Block {
+	preds := make(map[*ir.Block][]*ir.Block, len(blocks))
+	for _, b := range blocks {
+		for _, s := range successors(b) {
+			preds[s] = append(preds[s], b)
+		}
+	}
+	return preds
+}
+
+// --- Dominators (Cooper/Harvey/Kennedy) -------------------------------
+
+func computeDominators(blocks []*ir.Block, preds map[*ir.Block][]*ir.Block) map[*ir.Block]*ir.Block {
+	entry := blocks[0]
+
+	var postOrder []*ir.Block
+	visited := make(map[*ir.Block]bool)
+	var visit func(b *ir.Block)
+	visit = func(b *ir.Block) {
+		if visited[b] {
+			return
+		}
+		visited[b] = true
+		for _, s := range successors(b) {
+			visit(s)
+		}
+		postOrder = append(postOrder, b)
+	}
+	visit(entry)
+
+	postIndex := make(map[*ir.Block]int, len(postOrder))
+	for i, b := range postOrder {
+		postIndex[b] = i
+	}
+
+	rpo := make([]*ir.Block, len(postOrder))
+	for i, b := range postOrder {
+		rpo[len(postOrder)-1-i] = b
+	}
+
+	idom := make(map[*ir.Block]*ir.Block, len(rpo))
+	idom[entry] = entry
+
+	for changed := true; changed; {
+		changed = false
+		for _, b := range rpo {
+			if b == entry {
+				continue
+			}
+
+			var newIdom *ir.Block
+			for _, p := range preds[b] {
+				if idom[p] == nil {
+					continue
+				}
+				if newIdom == nil {
+					newIdom = p
+				} else {
+					newIdom = intersect(newIdom, p, idom, postIndex)
+				}
+			}
+
+			if idom[b] != newIdom {
+				idom[b] = newIdom
+				changed = true
+			}
+		}
+	}
+
+	delete(idom, entry) // entry has no strict dominator
+	return idom
+}
+
+func intersect(a, b *ir.Block, idom map[*ir.Block]*ir.Block, postIndex map[*ir.Block]int) *ir.Block {
+	for a != b {
+		for postIndex[a] < postIndex[b] {
+			a = idom[a]
+		}
+		for postIndex[b] < postIndex[a] {
+			b = idom[b]
+		}
+	}
+	return a
+}
+
+func dominatorChildren(idom map[*ir.Block]*ir.Block) map[*ir.Block][]*ir.Block {
+	children := make(map[*ir.Block][]*ir.Block, len(idom))
+	for b, p := range idom {
+		children[p] = append(children[p], b)
+	}
+	return children
+}
+
+func computeDominanceFrontier(blocks []*ir.Block, idom map[*ir.Block]*ir.Block, preds map[*ir.Block][]*ir.Block) map[*ir.Block][]*ir.Block {
+	df := make(map[*ir.Block][]*ir.Block)
+	for _, b := range blocks {
+		ps := preds[b]
+		if len(ps) < 2 {
+			continue
+		}
+		for _, p := range ps {
+			for runner := p; runner != nil && runner != idom[b]; runner = idom[runner] {
+				df[runner] = appendUnique(df[runner], b)
+			}
+		}
+	}
+	return df
+}
+
+func appendUnique(blocks []*ir.Block, b *ir.Block) []*ir.Block {
+	for _, existing := range blocks {
+		if existing == b {
+			return blocks
+		}
+	}
+	return append(blocks, b)
+}
+
+// --- Liftability -------------------------------------------------------
+
+func liftableAllocas(fn *ir.Func) []*ir.InstAlloca {
+	var out []*ir.InstAlloca
+	for _, b := range fn.Blocks {
+		for _, inst := range b.Insts {
+			a, ok := inst.(*ir.InstAlloca)
+			if ok && isScalarType(a.ElemType) && !isAddressTaken(fn, a) {
+				out = append(out, a)
+			}
+		}
+	}
+	return out
+}
+
+func isScalarType(t types.Type) bool {
+	_, ok := t.(*types.IntType)
+	return ok
+}
+
+// isAddressTaken reports whether a appears as an operand anywhere other
+// than the pointer position of a load or store, which would mean its
+// address escapes and it can't be promoted to an SSA value. It shares
+// operandPtrs/termOperandPtrs with replaceUses below: those two already
+// enumerate every "real" value operand slot (deliberately excluding the
+// sanctioned load-src/store-dst pointer positions), so address-taken
+// detection is just "does a show up in any of them".
+func isAddressTaken(fn *ir.Func, a *ir.InstAlloca) bool {
+	for _, b := range fn.Blocks {
+		for _, inst := range b.Insts {
+			for _, ptr := range operandPtrs(inst) {
+				if *ptr == a {
+					return true
+				}
+			}
+		}
+		for _, ptr := range termOperandPtrs(b.Term) {
+			if *ptr == a {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func defBlocks(fn *ir.Func, a *ir.InstAlloca) map[*ir.Block]bool {
+	defs := make(map[*ir.Block]bool)
+	for _, b := range fn.Blocks {
+		for _, inst := range b.Insts {
+			if s, ok := inst.(*ir.InstStore); ok && s.Dst == a {
+				defs[b] = true
+			}
+		}
+	}
+	return defs
+}
+
+// --- Phi placement -----------------------------------------------------
+
+func insertPhis(info *allocaInfo, frontier map[*ir.Block][]*ir.Block, preds map[*ir.Block][]*ir.Block) {
+	var worklist []*ir.Block
+	for b := range info.defs {
+		worklist = append(worklist, b)
+	}
+
+	for len(worklist) > 0 {
+		b := worklist[len(worklist)-1]
+		worklist = worklist[:len(worklist)-1]
+
+		for _, f := range frontier[b] {
+			if _, ok := info.phis[f]; ok {
+				continue
+			}
+
+			incs := make([]*ir.Incoming, 0, len(preds[f]))
+			for _, p := range preds[f] {
+				incs = append(incs, ir.NewIncoming(constant.NewUndef(info.alloca.ElemType), p))
+			}
+			phi := f.NewPhi(incs...)
+			moveToFront(f, phi)
+			info.phis[f] = phi
+
+			if !info.defs[f] {
+				worklist = append(worklist, f)
+			}
+		}
+	}
+}
+
+func moveToFront(b *ir.Block, inst ir.Instruction) {
+	for i, in := range b.Insts {
+		if in == inst {
+			copy(b.Insts[1:i+1], b.Insts[:i])
+			b.Insts[0] = inst
+			return
+		}
+	}
+}
+
+// --- Renaming ------------------------------------------------------------
+
+// renameAndRemove walks the dominator tree from entry, maintaining a
+// value stack per alloca. Loads are replaced by the top of their
+// alloca's stack, stores push a new top (and are dropped), and phi
+// incomings on successor blocks are filled in as each block finishes.
+// Stack pushes are unwound on the way back up the tree, matching the
+// classic Cytron et al. rename pass.
+func renameAndRemove(fn *ir.Func, entry *ir.Block, children map[*ir.Block][]*ir.Block, infos []*allocaInfo) {
+	stacks := make(map[*ir.InstAlloca][]value.Value, len(infos))
+	infoByAlloca := make(map[*ir.InstAlloca]*allocaInfo, len(infos))
+	for _, info := range infos {
+		infoByAlloca[info.alloca] = info
+		// Seed with undef so a load on a path with no dominating store
+		// (a not-definitely-assigned local) lowers to undef instead of
+		// indexing an empty stack, mirroring insertPhis's own use of
+		// undef for unreachable-predecessor phi incomings.
+		stacks[info.alloca] = []value.Value{constant.NewUndef(info.alloca.ElemType)}
+	}
+
+	var walk func(b *ir.Block)
+	walk = func(b *ir.Block) {
+		pushCounts := make(map[*ir.InstAlloca]int)
+		push := func(a *ir.InstAlloca, v value.Value) {
+			stacks[a] = append(stacks[a], v)
+			pushCounts[a]++
+		}
+
+		for _, info := range infos {
+			if phi, ok := info.phis[b]; ok {
+				push(info.alloca, phi)
+			}
+		}
+
+		newInsts := b.Insts[:0]
+		for _, inst := range b.Insts {
+			switch in := inst.(type) {
+			case *ir.InstLoad:
+				if a, ok := in.Src.(*ir.InstAlloca); ok {
+					if _, tracked := infoByAlloca[a]; tracked {
+						replaceUses(fn, in, top(stacks[a]))
+						continue
+					}
+				}
+			case *ir.InstStore:
+				if a, ok := in.Dst.(*ir.InstAlloca); ok {
+					if _, tracked := infoByAlloca[a]; tracked {
+						push(a, in.Src)
+						continue
+					}
+				}
+			case *ir.InstAlloca:
+				if _, tracked := infoByAlloca[in]; tracked {
+					continue
+				}
+			}
+			newInsts = append(newInsts, inst)
+		}
+		b.Insts = newInsts
+
+		for _, succ := range successors(b) {
+			for _, info := range infos {
+				if phi, ok := info.phis[succ]; ok {
+					setIncoming(phi, b, top(stacks[info.alloca]))
+				}
+			}
+		}
+
+		for _, c := range children[b] {
+			walk(c)
+		}
+
+		for a, n := range pushCounts {
+			stacks[a] = stacks[a][:len(stacks[a])-n]
+		}
+	}
+
+	walk(entry)
+}
+
+func top(stack []value.Value) value.Value {
+	return stack[len(stack)-1]
+}
+
+func setIncoming(phi *ir.InstPhi, pred *ir.Block, v value.Value) {
+	for _, inc := range phi.Incs {
+		if inc.Pred == pred {
+			inc.X = v
+		}
+	}
+}
+
+// replaceUses rewrites every operand in fn that points at old to point
+// at new instead. Used to retire a load once its value is known.
+func replaceUses(fn *ir.Func, old, new value.Value) {
+	for _, b := range fn.Blocks {
+		for _, inst := range b.Insts {
+			for _, ptr := range operandPtrs(inst) {
+				if *ptr == old {
+					*ptr = new
+				}
+			}
+		}
+		for _, ptr := range termOperandPtrs(b.Term) {
+			if *ptr == old {
+				*ptr = new
+			}
+		}
+	}
+}
+
+// operandPtrs returns a pointer to every value operand of inst that can
+// reference another instruction's result, deliberately excluding the
+// sanctioned pointer position of loads and stores (InstLoad.Src and
+// InstStore.Dst), which are handled directly by the rename walk above.
+// Every instruction kind the builder can emit belongs in this single
+// exhaustive walk, so isAddressTaken and replaceUses can't silently miss
+// an operand slot when a new instruction kind is added.
+func operandPtrs(inst ir.Instruction) []*value.Value {
+	switch in := inst.(type) {
+	case *ir.InstStore:
+		return []*value.Value{&in.Src}
+	case *ir.InstCall:
+		ptrs := make([]*value.Value, len(in.Args))
+		for i := range in.Args {
+			ptrs[i] = &in.Args[i]
+		}
+		return ptrs
+	case *ir.InstAdd:
+		return []*value.Value{&in.X, &in.Y}
+	case *ir.InstSub:
+		return []*value.Value{&in.X, &in.Y}
+	case *ir.InstMul:
+		return []*value.Value{&in.X, &in.Y}
+	case *ir.InstSDiv:
+		return []*value.Value{&in.X, &in.Y}
+	case *ir.InstSRem:
+		return []*value.Value{&in.X, &in.Y}
+	case *ir.InstICmp:
+		return []*value.Value{&in.X, &in.Y}
+	case *ir.InstZExt:
+		return []*value.Value{&in.From}
+	case *ir.InstGetElementPtr:
+		ptrs := make([]*value.Value, 0, 1+len(in.Indices))
+		ptrs = append(ptrs, &in.Src)
+		for i := range in.Indices {
+			ptrs = append(ptrs, &in.Indices[i])
+		}
+		return ptrs
+	case *ir.InstPhi:
+		ptrs := make([]*value.Value, len(in.Incs))
+		for i, inc := range in.Incs {
+			ptrs[i] = &inc.X
+		}
+		return ptrs
+	default:
+		return nil
+	}
+}
+
+func termOperandPtrs(term ir.Terminator) []*value.Value {
+	switch t := term.(type) {
+	case *ir.TermRet:
+		if t.X == nil {
+			return nil
+		}
+		return []*value.Value{&t.X}
+	case *ir.TermCondBr:
+		return []*value.Value{&t.Cond}
+	default:
+		return nil
+	}
+}
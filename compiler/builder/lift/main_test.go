@@ -0,0 +1,41 @@
+package lift
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+)
+
+// TestRun_TrivialLocalBecomesConstantReturn covers the mem2reg trivial
+// case: `int x = 5; return x;` should lift away the alloca entirely,
+// leaving a bare `ret i32 5`.
+func TestRun_TrivialLocalBecomesConstantReturn(t *testing.T) {
+	module := ir.NewModule()
+	fn := module.NewFunc("f", types.I32)
+	entry := fn.NewBlock("")
+
+	x := entry.NewAlloca(types.I32)
+	x.SetName("x")
+	entry.NewStore(constant.NewInt(types.I32, 5), x)
+	load := entry.NewLoad(types.I32, x)
+	entry.NewRet(load)
+
+	Run(fn)
+
+	for _, inst := range entry.Insts {
+		if _, ok := inst.(*ir.InstAlloca); ok {
+			t.Fatalf("expected no allocas after lifting, got:\n%s", fn.LLString())
+		}
+	}
+	if len(entry.Insts) != 0 {
+		t.Fatalf("expected no instructions left in entry once the alloca, store, and load are lifted away, got:\n%s", fn.LLString())
+	}
+
+	got := fn.LLString()
+	if !strings.Contains(got, "ret i32 5") {
+		t.Fatalf("expected the lifted return to carry the stored constant directly, got:\n%s", got)
+	}
+}
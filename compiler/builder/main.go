@@ -3,8 +3,11 @@ package builder
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"fuc.eparker.dev/compiler/ast"
+	"fuc.eparker.dev/compiler/builder/blockopt"
+	"fuc.eparker.dev/compiler/builder/lift"
 	"github.com/llir/llvm/ir"
 	"github.com/llir/llvm/ir/constant"
 	"github.com/llir/llvm/ir/enum"
@@ -83,6 +86,14 @@ import (
     }
 }*/
 
+// funcSymbol is the CREATE-phase record for a function: its signature is
+// already registered in the module, but decl is only non-nil for functions
+// with an AST body still waiting to be filled in by the BUILD phase.
+type funcSymbol struct {
+	fn   *ir.Func
+	decl *ast.ASTNode
+}
+
 type Builder struct {
 	ast      *ast.ASTNode
 	module   *ir.Module
@@ -90,14 +101,43 @@ type Builder struct {
 	block    *ir.Block
 	locals   map[string]value.Value
 	globals  map[string]constant.Constant
+
+	// symbols holds every function signature discovered during the CREATE
+	// phase, keyed by name, so the BUILD phase (and calls within it) can
+	// resolve any function regardless of source order. order preserves a
+	// stable iteration order for the BUILD phase.
+	symbols map[string]*funcSymbol
+	order   []string
+
+	// loopStack tracks the break/continue targets of the loops currently
+	// being generated, innermost last.
+	loopStack []loopFrame
+
+	// structs holds every struct type registered during the CREATE phase,
+	// keyed by name. structFields maps each struct name to its field
+	// name -> GEP index, for field selection.
+	structs      map[string]*types.StructType
+	structFields map[string]map[string]int
+}
+
+// loopFrame records where `break` and `continue` should branch to for
+// one enclosing loop. label is set from the loop's own AST node name and
+// lets a labeled break/continue reach past inner loops.
+type loopFrame struct {
+	label          string
+	continueTarget *ir.Block
+	breakTarget    *ir.Block
 }
 
 func NewBuilder(ast *ast.ASTNode) *Builder {
 	return &Builder{
-		ast:     ast,
-		module:  ir.NewModule(),
-		locals:  make(map[string]value.Value),
-		globals: make(map[string]constant.Constant),
+		ast:          ast,
+		module:       ir.NewModule(),
+		locals:       make(map[string]value.Value),
+		globals:      make(map[string]constant.Constant),
+		symbols:      make(map[string]*funcSymbol),
+		structs:      make(map[string]*types.StructType),
+		structFields: make(map[string]map[string]int),
 	}
 }
 
@@ -128,22 +168,94 @@ func (b *Builder) SetTarget(target targetType) {
 	}
 }
 
+// Optimize runs the post-Build() cleanup passes over every function in
+// the module: level 0 is a no-op, level 1 runs blockopt alone, level 2
+// also runs lift (mem2reg), which relies on blockopt having already
+// pruned unreachable blocks so dominance is computed over real CFG edges.
+func (b *Builder) Optimize(level int) {
+	if level < 1 {
+		return
+	}
+
+	for _, fn := range b.module.Funcs {
+		blockopt.Run(fn)
+	}
+
+	if level < 2 {
+		return
+	}
+
+	for _, fn := range b.module.Funcs {
+		lift.Run(fn)
+	}
+}
+
+// Build runs the CREATE phase over the whole AST, registering every
+// function signature and global constant up front, then runs the BUILD
+// phase to fill in each function's blocks. Splitting the two means a
+// function body can call any other function regardless of where it
+// appears in the source, including itself or a function that calls it
+// back (mutual recursion).
 func (b *Builder) Build() *ir.Module {
-	b.generateProgram(b.ast)
+	b.create(b.ast)
+	b.buildAll()
 	return b.module
 }
 
-func (b *Builder) generateProgram(node *ast.ASTNode) {
+func (b *Builder) create(node *ast.ASTNode) {
+	// Struct layouts are registered in their own pass first, since a
+	// function signature may reference one before its declaration is
+	// reached in source order. Structs themselves are registered in
+	// source order, so a struct field can only name a struct declared
+	// before it.
+	for _, child := range node.Children {
+		if child.Type == ast.StructDeclaration {
+			b.createStruct(child)
+		}
+	}
+
 	for _, child := range node.Children {
 		switch child.Type {
 		case ast.PreprocessorDirective:
 			b.generatePreprocessorDirective(child)
 		case ast.FunctionDeclaration:
-			b.generateFunction(child)
+			b.createFunctionSignature(child)
 		}
 	}
 }
 
+// createStruct registers a struct's field layout so getTypeFromName can
+// resolve it by name and generateFieldAccess can translate a field name
+// to a GEP index.
+func (b *Builder) createStruct(node *ast.ASTNode) {
+	name := node.Name
+
+	fieldTypes := make([]types.Type, len(node.Children))
+	fieldIndex := make(map[string]int, len(node.Children))
+	for i, field := range node.Children {
+		fieldIndex[field.Name] = i
+		fieldTypes[i] = b.getTypeFromName(field.Children[0].Name)
+	}
+
+	st := types.NewStruct(fieldTypes...)
+	st.TypeName = name
+	b.module.NewTypeDef(name, st)
+
+	b.structs[name] = st
+	b.structFields[name] = fieldIndex
+}
+
+func (b *Builder) buildAll() {
+	for _, name := range b.order {
+		sym := b.symbols[name]
+		if sym.decl == nil {
+			// Extern (e.g. printf) minted lazily at a call site; no body.
+			continue
+		}
+		b.buildFunction(sym)
+	}
+}
+
 func (b *Builder) generatePreprocessorDirective(node *ast.ASTNode) {
 	if node.Name == "#define" && len(node.Children) == 2 {
 		name := node.Children[0].Name
@@ -165,16 +277,20 @@ func (b *Builder) generatePreprocessorDirective(node *ast.ASTNode) {
 	panic(fmt.Sprintf("Unsupported preprocessor directive: %v", node.Name))
 }
 
-func (b *Builder) generateFunction(node *ast.ASTNode) {
+// createFunctionSignature allocates the *ir.Func (return type, params,
+// variadic flag) and registers it in the symbol table, without emitting
+// any block bodies. The AST node is kept alongside so buildFunction can
+// fill it in later, once every signature in the program is known.
+func (b *Builder) createFunctionSignature(node *ast.ASTNode) {
 	name := node.Name
-	retType := getTypeFromName(node.Children[0].Name)
+	retType := b.getTypeFromName(node.Children[0].Name)
 
 	// Create parameter types
 	var paramTypes []types.Type
 	var paramNames []string
 	for _, param := range node.Children[1].Children {
 		paramName := param.Name
-		paramType := getTypeFromName(param.Children[0].Name)
+		paramType := b.getTypeFromName(param.Children[0].Name)
 		paramTypes = append(paramTypes, paramType)
 		paramNames = append(paramNames, paramName)
 	}
@@ -195,12 +311,23 @@ func (b *Builder) generateFunction(node *ast.ASTNode) {
 
 	// Create function
 	fn := b.module.NewFunc(name, funcType.RetType, params...)
-
-	// Set parameter names and add them to locals
-	b.locals = make(map[string]value.Value) // Clear locals for the new function
 	for i, param := range fn.Params {
 		param.SetName(paramNames[i])
-		b.locals[paramNames[i]] = param
+	}
+
+	b.symbols[name] = &funcSymbol{fn: fn, decl: node}
+	b.order = append(b.order, name)
+}
+
+// buildFunction fills in the blocks for a function whose signature was
+// already registered during the CREATE phase.
+func (b *Builder) buildFunction(sym *funcSymbol) {
+	node := sym.decl
+	fn := sym.fn
+
+	b.locals = make(map[string]value.Value) // Clear locals for the new function
+	for _, param := range fn.Params {
+		b.locals[param.Name()] = param
 	}
 
 	b.function = fn
@@ -217,25 +344,21 @@ func (b *Builder) generateFunction(node *ast.ASTNode) {
 
 func (b *Builder) generateFunctionCall(node *ast.ASTNode) value.Value {
 	fnName := node.Name
-	var fn *ir.Func
-
-	// Find the function in the module
-	for _, f := range b.module.Funcs {
-		if f.Name() == fnName {
-			fn = f
-			break
-		}
-	}
 
-	if fn == nil {
-		if fnName == "printf" {
-			// Create printf function
-			fn = b.module.NewFunc("printf", types.I32, ir.NewParam("format", types.NewPointer(types.I8)))
-			fn.Sig.Variadic = true
-		} else {
-			// Function not found
+	sym, ok := b.symbols[fnName]
+	if !ok {
+		if fnName != "printf" {
 			panic(fmt.Sprintf("Function not found: %s", fnName))
 		}
+
+		// Libc externs like printf aren't declared in the AST, so mint
+		// them lazily on first use and register them in the symbol table
+		// so later calls resolve them in O(1) instead of re-minting.
+		fn := b.module.NewFunc("printf", types.I32, ir.NewParam("format", types.NewPointer(types.I8)))
+		fn.Sig.Variadic = true
+		sym = &funcSymbol{fn: fn}
+		b.symbols[fnName] = sym
+		b.order = append(b.order, fnName)
 	}
 
 	var args []value.Value
@@ -248,7 +371,7 @@ func (b *Builder) generateFunctionCall(node *ast.ASTNode) value.Value {
 		args = append(args, b.generateExpression(arg))
 	}
 
-	return b.block.NewCall(fn, args...)
+	return b.block.NewCall(sym.fn, args...)
 }
 
 func (b *Builder) generateBlock(block *ir.Block, node *ast.ASTNode) {
@@ -281,6 +404,8 @@ func (b *Builder) generateBlock(block *ir.Block, node *ast.ASTNode) {
 			b.generateAssignment(child)
 		case ast.WhileStatement:
 			b.generateWhileStatement(child)
+		case ast.ForStatement:
+			b.generateForStatement(child)
 		default:
 			panic(fmt.Sprintf("Unsupported block type: %d", child.Type))
 		}
@@ -291,20 +416,20 @@ func (b *Builder) generateReturn(node *ast.ASTNode) {
 	if len(node.Children) == 0 {
 		b.block.NewRet(nil)
 	} else {
-		value := b.generateExpression(node.Children[0])
+		value := b.toI32(b.generateExpression(node.Children[0]))
 		b.block.NewRet(value)
 	}
 }
 
 func (b *Builder) generateVariableDeclaration(node *ast.ASTNode) {
 	varName := node.Name
-	varType := getTypeFromName(node.Children[0].Name)
+	varType := b.getTypeFromName(node.Children[0].Name)
 	alloca := b.block.NewAlloca(varType)
 	alloca.SetName(varName)
 	b.locals[varName] = alloca
 
 	if len(node.Children) > 1 {
-		value := b.generateExpression(node.Children[1])
+		value := b.toI32(b.generateExpression(node.Children[1]))
 		b.block.NewStore(value, alloca)
 	}
 }
@@ -337,24 +462,31 @@ func (b *Builder) generateConditional(node *ast.ASTNode) {
 	b.block = endBlock
 }
 
+// generateAssignment handles both the simple `x op= expr` form, where the
+// target is a named local, and the extended lvalue form produced for
+// array/pointer/field targets (`a[i] op= expr`, `*p op= expr`,
+// `s.field op= expr`), where the target is itself an expression node
+// evaluated for its address.
 func (b *Builder) generateAssignment(node *ast.ASTNode) {
-	varName := node.Name
-	operator := node.Children[0].Name
-	rightExpr := b.generateExpression(node.Children[1])
-
-	alloca, ok := b.locals[varName]
-
-	if !ok {
-		panic(fmt.Sprintf("Undefined variable: %s", varName))
+	var target value.Value
+	var operator string
+	var rhsNode *ast.ASTNode
+
+	if node.Name != "" {
+		target = b.localAddress(node.Name)
+		operator = node.Children[0].Name
+		rhsNode = node.Children[1]
+	} else {
+		target = b.generateAddress(node.Children[0])
+		operator = node.Children[1].Name
+		rhsNode = node.Children[2]
 	}
 
-	// If it's a IntType and not a PointerType, handle differently
-	if _, isParam := alloca.(*ir.Param); isParam {
-		alloca = b.block.NewAlloca(alloca.Type())
-		b.block.NewStore(b.locals[varName], alloca)
-	}
+	rightExpr := b.generateExpression(rhsNode)
+	elemType := target.Type().(*types.PointerType).ElemType
+	loadInst := b.block.NewLoad(elemType, target)
 
-	loadInst := b.block.NewLoad(alloca.Type().(*types.PointerType).ElemType, alloca)
+	rightExpr = b.toI32(rightExpr)
 
 	var result value.Value
 	switch operator {
@@ -374,18 +506,53 @@ func (b *Builder) generateAssignment(node *ast.ASTNode) {
 		panic(fmt.Sprintf("Unsupported assignment operator: %s", operator))
 	}
 
-	b.block.NewStore(result, alloca)
-	b.locals[varName] = alloca
+	b.block.NewStore(result, target)
+}
+
+// localAddress returns the address of a named local, promoting it from a
+// raw parameter to an alloca on first use (so it has an address to take).
+func (b *Builder) localAddress(name string) value.Value {
+	val, ok := b.locals[name]
+	if !ok {
+		panic(fmt.Sprintf("Undefined variable: %s", name))
+	}
+
+	if _, isParam := val.(*ir.Param); isParam {
+		alloca := b.block.NewAlloca(val.Type())
+		b.block.NewStore(val, alloca)
+		b.locals[name] = alloca
+		val = alloca
+	}
+
+	return val
 }
 
+// generateExpression evaluates node for its value. generateAddress
+// evaluates it for its address instead, e.g. as the operand of `&` or
+// the target of an assignment; the same AST node produces either a value
+// or an address depending on which one the caller asks for.
 func (b *Builder) generateExpression(node *ast.ASTNode) value.Value {
+	return b.generateExpressionMode(node, false)
+}
+
+func (b *Builder) generateAddress(node *ast.ASTNode) value.Value {
+	return b.generateExpressionMode(node, true)
+}
+
+func (b *Builder) generateExpressionMode(node *ast.ASTNode, wantAddr bool) value.Value {
 	switch node.Type {
 	case ast.Literal:
 		return b.generateLiteral(node)
 	case ast.Identifier:
-		return b.generateIdentifier(node)
+		return b.generateIdentifierMode(node, wantAddr)
 	case ast.BinaryExpression:
 		return b.generateBinaryExpression(node)
+	case ast.UnaryExpression:
+		return b.generateUnaryExpressionMode(node, wantAddr)
+	case ast.ArrayAccess:
+		return b.generateArrayAccess(node, wantAddr)
+	case ast.FieldAccess:
+		return b.generateFieldAccess(node, wantAddr)
 	case ast.FunctionCall:
 		return b.generateFunctionCall(node)
 	default:
@@ -393,6 +560,29 @@ func (b *Builder) generateExpression(node *ast.ASTNode) value.Value {
 	}
 }
 
+// toI32 widens an i1 (the result of a comparison or logical expression)
+// to i32 for contexts that expect an integer value, e.g. storing into an
+// int local or returning from an int function. Anything else passes
+// through unchanged.
+func (b *Builder) toI32(v value.Value) value.Value {
+	if v.Type() == types.I1 {
+		return b.block.NewZExt(v, types.I32)
+	}
+	return v
+}
+
+// toI1 narrows a plain int (e.g. a local or literal used as a boolean)
+// to i1 via `!= 0`, for contexts that require an i1 operand, e.g. a
+// CondBr condition or a logical operator. A value that's already i1
+// (the result of a comparison or logical expression) passes through
+// unchanged.
+func (b *Builder) toI1(v value.Value) value.Value {
+	if v.Type() == types.I1 {
+		return v
+	}
+	return b.block.NewICmp(enum.IPredNE, v, constant.NewInt(v.Type().(*types.IntType), 0))
+}
+
 func (b *Builder) generateLiteral(node *ast.ASTNode) value.Value {
 	val, err := strconv.Atoi(node.Name)
 	if err != nil {
@@ -401,37 +591,123 @@ func (b *Builder) generateLiteral(node *ast.ASTNode) value.Value {
 	return constant.NewInt(types.I32, int64(val))
 }
 
-func (b *Builder) generateIdentifier(node *ast.ASTNode) value.Value {
-	if val, ok := b.locals[node.Name]; ok {
-		if _, isParam := val.(*ir.Param); isParam {
-			return val // Return the parameter directly
+func (b *Builder) generateIdentifierMode(node *ast.ASTNode, wantAddr bool) value.Value {
+	if _, ok := b.locals[node.Name]; ok {
+		addr := b.localAddress(node.Name)
+		if wantAddr {
+			return addr
 		}
-
-		return b.block.NewLoad(val.Type().(*types.PointerType).ElemType, val)
+		return b.block.NewLoad(addr.Type().(*types.PointerType).ElemType, addr)
 	}
 
 	if val, ok := b.globals[node.Name]; ok {
+		if wantAddr {
+			panic(fmt.Sprintf("Cannot take address of constant: %s", node.Name))
+		}
 		return val
 	}
 
 	panic(fmt.Sprintf("Undefined variable: %s", node.Name))
 }
 
+// generateUnaryExpressionMode threads wantAddr through `&` and `*` so the
+// same operand expression can be evaluated for its address or its value:
+// `&x` returns x's address directly instead of loading it, and `*p` loads
+// through the pointer unless the caller wants the pointer itself (e.g.
+// `*p = ...`).
+func (b *Builder) generateUnaryExpressionMode(node *ast.ASTNode, wantAddr bool) value.Value {
+	switch node.Name {
+	case "!":
+		operand := b.toI1(b.generateExpression(node.Children[0]))
+		return b.block.NewICmp(enum.IPredEQ, operand, constant.NewBool(false))
+	case "&":
+		return b.generateAddress(node.Children[0])
+	case "*":
+		ptr := b.generateExpression(node.Children[0])
+		if wantAddr {
+			return ptr
+		}
+		return b.block.NewLoad(ptr.Type().(*types.PointerType).ElemType, ptr)
+	default:
+		panic(fmt.Sprintf("Unsupported unary operator: %s", node.Name))
+	}
+}
+
+// generateArrayAccess lowers `a[i]`. For a fixed-size array local, the
+// base is its own address, so the GEP needs the leading [i32 0] to step
+// into the array before indexing. For a pointer local, the base is the
+// pointer's value, so the GEP just offsets it by i.
+func (b *Builder) generateArrayAccess(node *ast.ASTNode, wantAddr bool) value.Value {
+	baseNode := node.Children[0]
+	index := b.generateExpression(node.Children[1])
+
+	baseAddr := b.generateAddress(baseNode)
+	baseElemType := baseAddr.Type().(*types.PointerType).ElemType
+
+	var elemPtr *ir.InstGetElementPtr
+	if arrType, ok := baseElemType.(*types.ArrayType); ok {
+		elemPtr = b.block.NewGetElementPtr(arrType, baseAddr, constant.NewInt(types.I32, 0), index)
+	} else {
+		ptrVal := b.block.NewLoad(baseElemType, baseAddr)
+		elemPtr = b.block.NewGetElementPtr(baseElemType.(*types.PointerType).ElemType, ptrVal, index)
+	}
+
+	if wantAddr {
+		return elemPtr
+	}
+	return b.block.NewLoad(elemPtr.Type().(*types.PointerType).ElemType, elemPtr)
+}
+
+// generateFieldAccess lowers `s.field` to a GEP into s's struct type at
+// the field's declared index, loading the field unless the caller wants
+// its address (e.g. `s.field = ...` or `&s.field`).
+func (b *Builder) generateFieldAccess(node *ast.ASTNode, wantAddr bool) value.Value {
+	baseAddr := b.generateAddress(node.Children[0])
+
+	st, ok := baseAddr.Type().(*types.PointerType).ElemType.(*types.StructType)
+	if !ok {
+		panic(fmt.Sprintf("Field access on non-struct value: %s", node.Name))
+	}
+
+	fields, ok := b.structFields[st.TypeName]
+	if !ok {
+		panic(fmt.Sprintf("Unknown struct type: %s", st.TypeName))
+	}
+	index, ok := fields[node.Name]
+	if !ok {
+		panic(fmt.Sprintf("Unknown field: %s", node.Name))
+	}
+
+	fieldPtr := b.block.NewGetElementPtr(st, baseAddr, constant.NewInt(types.I32, 0), constant.NewInt(types.I32, int64(index)))
+
+	if wantAddr {
+		return fieldPtr
+	}
+	return b.block.NewLoad(fieldPtr.Type().(*types.PointerType).ElemType, fieldPtr)
+}
+
 func (b *Builder) generateBinaryExpression(node *ast.ASTNode) value.Value {
+	switch node.Name {
+	case "&&":
+		return b.generateLogicalAnd(node)
+	case "||":
+		return b.generateLogicalOr(node)
+	}
+
 	left := b.generateExpression(node.Children[0])
 	right := b.generateExpression(node.Children[1])
 
 	switch node.Name {
 	case "+":
-		return b.block.NewAdd(left, right)
+		return b.block.NewAdd(b.toI32(left), b.toI32(right))
 	case "-":
-		return b.block.NewSub(left, right)
+		return b.block.NewSub(b.toI32(left), b.toI32(right))
 	case "*":
-		return b.block.NewMul(left, right)
+		return b.block.NewMul(b.toI32(left), b.toI32(right))
 	case "/":
-		return b.block.NewSDiv(left, right)
+		return b.block.NewSDiv(b.toI32(left), b.toI32(right))
 	case "%":
-		return b.block.NewSRem(left, right)
+		return b.block.NewSRem(b.toI32(left), b.toI32(right))
 	case "==":
 		return b.block.NewICmp(enum.IPredEQ, left, right)
 	case "!=":
@@ -449,6 +725,53 @@ func (b *Builder) generateBinaryExpression(node *ast.ASTNode) value.Value {
 	}
 }
 
+// generateLogicalAnd lazily evaluates `L && R`: R is only evaluated if L
+// is true, and the result is phi'd from false (L was false) or R's value
+// (L was true). Evaluating R may itself branch into further blocks if it
+// contains nested short-circuits, so the phi's R-incoming block is
+// b.block as left *after* generating R, not rhsBlock itself.
+func (b *Builder) generateLogicalAnd(node *ast.ASTNode) value.Value {
+	left := b.toI1(b.generateExpression(node.Children[0]))
+	startBlock := b.block
+
+	rhsBlock := b.function.NewBlock("")
+	endBlock := b.function.NewBlock("")
+	b.block.NewCondBr(left, rhsBlock, endBlock)
+
+	b.block = rhsBlock
+	right := b.toI1(b.generateExpression(node.Children[1]))
+	rhsEndBlock := b.block
+	rhsEndBlock.NewBr(endBlock)
+
+	b.block = endBlock
+	return endBlock.NewPhi(
+		ir.NewIncoming(constant.NewBool(false), startBlock),
+		ir.NewIncoming(right, rhsEndBlock),
+	)
+}
+
+// generateLogicalOr mirrors generateLogicalAnd: R is only evaluated if L
+// is false.
+func (b *Builder) generateLogicalOr(node *ast.ASTNode) value.Value {
+	left := b.toI1(b.generateExpression(node.Children[0]))
+	startBlock := b.block
+
+	rhsBlock := b.function.NewBlock("")
+	endBlock := b.function.NewBlock("")
+	b.block.NewCondBr(left, endBlock, rhsBlock)
+
+	b.block = rhsBlock
+	right := b.toI1(b.generateExpression(node.Children[1]))
+	rhsEndBlock := b.block
+	rhsEndBlock.NewBr(endBlock)
+
+	b.block = endBlock
+	return endBlock.NewPhi(
+		ir.NewIncoming(constant.NewBool(true), startBlock),
+		ir.NewIncoming(right, rhsEndBlock),
+	)
+}
+
 func (b *Builder) generateWhileStatement(node *ast.ASTNode) {
 	conditionBlock := b.function.NewBlock("")
 	loopBlock := b.function.NewBlock("")
@@ -460,7 +783,14 @@ func (b *Builder) generateWhileStatement(node *ast.ASTNode) {
 	condition := b.generateExpression(node.Children[0])
 	b.block.NewCondBr(condition, loopBlock, endBlock)
 
+	b.loopStack = append(b.loopStack, loopFrame{
+		label:          node.Name,
+		continueTarget: conditionBlock,
+		breakTarget:    endBlock,
+	})
 	b.generateBlock(loopBlock, node.Children[1])
+	b.loopStack = b.loopStack[:len(b.loopStack)-1]
+
 	if loopBlock.Term == nil {
 		loopBlock.NewBr(conditionBlock)
 	}
@@ -468,15 +798,124 @@ func (b *Builder) generateWhileStatement(node *ast.ASTNode) {
 	b.block = endBlock
 }
 
-func getTypeFromName(name string) types.Type {
+// generateForStatement lowers init; cond; post { body } to the same
+// condition/loop/end block shape as generateWhileStatement, with a post
+// block inserted between the body and the condition so `continue`
+// re-runs the post-expression instead of skipping it.
+func (b *Builder) generateForStatement(node *ast.ASTNode) {
+	initNode, condNode, postNode, bodyNode := node.Children[0], node.Children[1], node.Children[2], node.Children[3]
+
+	b.generateForClause(initNode)
+
+	conditionBlock := b.function.NewBlock("")
+	loopBlock := b.function.NewBlock("")
+	postBlock := b.function.NewBlock("")
+	endBlock := b.function.NewBlock("")
+
+	b.block.NewBr(conditionBlock)
+
+	b.block = conditionBlock
+	condition := b.generateExpression(condNode)
+	b.block.NewCondBr(condition, loopBlock, endBlock)
+
+	b.loopStack = append(b.loopStack, loopFrame{
+		label:          node.Name,
+		continueTarget: postBlock,
+		breakTarget:    endBlock,
+	})
+	b.generateBlock(loopBlock, bodyNode)
+	b.loopStack = b.loopStack[:len(b.loopStack)-1]
+
+	if b.block.Term == nil {
+		b.block.NewBr(postBlock)
+	}
+
+	b.block = postBlock
+	b.generateForClause(postNode)
+	if b.block.Term == nil {
+		b.block.NewBr(conditionBlock)
+	}
+
+	b.block = endBlock
+}
+
+// generateForClause emits the optional init/post expression of a for
+// statement; an empty clause (e.g. both the init and the post of
+// `for (;i<10;)`) is a no-op.
+func (b *Builder) generateForClause(node *ast.ASTNode) {
+	switch node.Type {
+	case ast.VariableDeclaration:
+		b.generateVariableDeclaration(node)
+	case ast.Assignment:
+		b.generateAssignment(node)
+	case ast.FunctionCall:
+		b.generateFunctionCall(node)
+	}
+}
+
+// getTypeFromName parses a type name as it appears in the AST: "int",
+// "void", a pointer ("int*", "int**", ...), a fixed-size array
+// ("int[10]"), or a previously-declared struct name.
+func (b *Builder) getTypeFromName(name string) types.Type {
+	if strings.HasSuffix(name, "*") {
+		return types.NewPointer(b.getTypeFromName(strings.TrimSuffix(name, "*")))
+	}
+
+	if idx := strings.IndexByte(name, '['); idx != -1 && strings.HasSuffix(name, "]") {
+		base := b.getTypeFromName(name[:idx])
+
+		size, err := strconv.Atoi(name[idx+1 : len(name)-1])
+		if err != nil {
+			panic(fmt.Sprintf("Invalid array size: %s", name))
+		}
+
+		return types.NewArray(uint64(size), base)
+	}
+
 	switch name {
 	case "int":
 		return types.I32
 	case "void":
 		return types.Void
 	default:
+		if st, ok := b.structs[name]; ok {
+			return st
+		}
 		panic(fmt.Sprintf("Unsupported type: %s", name))
 	}
 }
 
-func (b *Builder) generateBreakContinue(node *ast.ASTNode, isContinue bool) {}
+// generateBreakContinue terminates the current block with a branch to
+// the innermost (or, if labeled, the matching) loop's continue/break
+// target, then opens a fresh block so any further statements in this AST
+// block still have somewhere valid to emit into; the block optimizer
+// prunes that now-unreachable block later.
+func (b *Builder) generateBreakContinue(node *ast.ASTNode, isContinue bool) {
+	if len(b.loopStack) == 0 {
+		panic(fmt.Sprintf("%s statement outside of a loop", node.Name))
+	}
+
+	frame := b.loopStack[len(b.loopStack)-1]
+	if len(node.Children) > 0 {
+		label := node.Children[0].Name
+		found := false
+		for i := len(b.loopStack) - 1; i >= 0; i-- {
+			if b.loopStack[i].label == label {
+				frame = b.loopStack[i]
+				found = true
+				break
+			}
+		}
+		if !found {
+			panic(fmt.Sprintf("Undefined loop label: %s", label))
+		}
+	}
+
+	if isContinue {
+		b.block.NewBr(frame.continueTarget)
+	} else {
+		b.block.NewBr(frame.breakTarget)
+	}
+
+	b.block = b.function.NewBlock("")
+}
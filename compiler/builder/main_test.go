@@ -0,0 +1,97 @@
+package builder
+
+import (
+	"strings"
+	"testing"
+
+	"fuc.eparker.dev/compiler/ast"
+)
+
+// Test AST construction helpers. There's no AST builder package to reuse
+// (the parser that normally produces these trees isn't part of this
+// tree), so tests build *ast.ASTNode literals by hand, following the
+// shape documented by the AST dump at the top of main.go.
+
+func ident(name string) *ast.ASTNode {
+	return &ast.ASTNode{Type: ast.Identifier, Name: name}
+}
+
+func literal(n string) *ast.ASTNode {
+	return &ast.ASTNode{Type: ast.Literal, Name: n}
+}
+
+func binary(op string, left, right *ast.ASTNode) *ast.ASTNode {
+	return &ast.ASTNode{Type: ast.BinaryExpression, Name: op, Children: []*ast.ASTNode{left, right}}
+}
+
+func call(name string, args ...*ast.ASTNode) *ast.ASTNode {
+	return &ast.ASTNode{Type: ast.FunctionCall, Name: name, Children: args}
+}
+
+func ret(expr *ast.ASTNode) *ast.ASTNode {
+	return &ast.ASTNode{Type: ast.ReturnStatement, Children: []*ast.ASTNode{expr}}
+}
+
+func ifStmt(cond, thenBlock *ast.ASTNode) *ast.ASTNode {
+	return &ast.ASTNode{Type: ast.Statement, Name: "if", Children: []*ast.ASTNode{cond, thenBlock}}
+}
+
+func block(stmts ...*ast.ASTNode) *ast.ASTNode {
+	return &ast.ASTNode{Type: ast.Block, Children: stmts}
+}
+
+func param(name, typeName string) *ast.ASTNode {
+	return &ast.ASTNode{Type: ast.VariableDeclaration, Name: name, Children: []*ast.ASTNode{ident(typeName)}}
+}
+
+func params(ps ...*ast.ASTNode) *ast.ASTNode {
+	return &ast.ASTNode{Type: ast.Parameters, Children: ps}
+}
+
+func funcDecl(name, retType string, ps *ast.ASTNode, body *ast.ASTNode) *ast.ASTNode {
+	return &ast.ASTNode{
+		Type: ast.FunctionDeclaration,
+		Name: name,
+		Children: []*ast.ASTNode{
+			ident(retType),
+			ps,
+			body,
+		},
+	}
+}
+
+func program(decls ...*ast.ASTNode) *ast.ASTNode {
+	return &ast.ASTNode{Type: ast.Program, Children: decls}
+}
+
+// TestBuild_ForwardReferenceAndMutualRecursion covers the whole point of
+// the CREATE/BUILD split: isEven calls isOdd before isOdd appears in
+// source (a forward reference), and isOdd calls back into isEven (mutual
+// recursion). Neither would resolve if function bodies were built in a
+// single pass over source order.
+func TestBuild_ForwardReferenceAndMutualRecursion(t *testing.T) {
+	isEven := funcDecl("isEven", "int", params(param("n", "int")),
+		block(
+			ifStmt(binary("==", ident("n"), literal("0")), block(ret(literal("1")))),
+			ret(call("isOdd", binary("-", ident("n"), literal("1")))),
+		),
+	)
+	isOdd := funcDecl("isOdd", "int", params(param("n", "int")),
+		block(
+			ifStmt(binary("==", ident("n"), literal("0")), block(ret(literal("0")))),
+			ret(call("isEven", binary("-", ident("n"), literal("1")))),
+		),
+	)
+
+	tree := program(isEven, isOdd)
+
+	module := NewBuilder(tree).Build()
+
+	got := module.String()
+	if !strings.Contains(got, "call i32 @isOdd") {
+		t.Fatalf("expected isEven's forward reference to isOdd to resolve, got:\n%s", got)
+	}
+	if !strings.Contains(got, "call i32 @isEven") {
+		t.Fatalf("expected isOdd's back-reference to isEven to resolve, got:\n%s", got)
+	}
+}